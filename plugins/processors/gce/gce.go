@@ -1,10 +1,16 @@
 package ec2
 
 import (
-	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/compute/metadata"
@@ -13,19 +19,87 @@ import (
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/plugins/common/parallel"
 	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 type GceProcessor struct {
-	AllowTags        []string        `toml:"allow_tags"`
-	Timeout          config.Duration `toml:"timeout"`
-	Ordered          bool            `toml:"ordered"`
-	MaxParallelCalls int             `toml:"max_parallel_calls"`
-	Log              telegraf.Logger `toml:"-"`
+	AllowTags               []string        `toml:"allow_tags"`
+	Timeout                 config.Duration `toml:"timeout"`
+	Ordered                 bool            `toml:"ordered"`
+	MaxParallelCalls        int             `toml:"max_parallel_calls"`
+	MetadataRefreshInterval config.Duration `toml:"metadata_refresh_interval"`
 
-	gceClient    *metadata.Client
-	allowTagsMap map[string]struct{}
-	parallel     parallel.Parallel
-	instanceID   string
+	AdaptiveConcurrency   bool            `toml:"adaptive_concurrency"`
+	MinParallelCalls      int             `toml:"min_parallel_calls"`
+	AdaptiveTargetLatency config.Duration `toml:"adaptive_target_latency"`
+	AdaptiveErrorThresh   float64         `toml:"adaptive_error_threshold"`
+	AdaptiveWindow        config.Duration `toml:"adaptive_window"`
+
+	MaxQueueWait        config.Duration `toml:"max_queue_wait"`
+	MaxQueuedMetrics    int             `toml:"max_queued_metrics"`
+	QueueOverflowPolicy string          `toml:"queue_overflow_policy"`
+
+	Metadata []MetadataEntry `toml:"metadata"`
+
+	RetryMaxAttempts        int             `toml:"retry_max_attempts"`
+	CircuitBreakerThreshold int             `toml:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  config.Duration `toml:"circuit_breaker_cooldown"`
+
+	EnableHTTP2           bool   `toml:"enable_http2"`
+	ProxyURL              string `toml:"proxy_url"`
+	TLSInsecureSkipVerify bool   `toml:"tls_insecure_skip_verify"`
+
+	Log telegraf.Logger `toml:"-"`
+
+	gceClient       *metadata.Client
+	allowTagsMap    map[string]struct{}
+	metadataEntries map[string]MetadataEntry
+	parallel        parallel.Parallel
+	instanceID      string
+
+	cache    map[string]*cacheEntry
+	cacheMu  sync.RWMutex
+	done     chan struct{}
+	stopOnce sync.Once
+
+	limiter   *adaptiveLimiter
+	limitStat selfstat.Stat
+	breaker   *circuitBreaker
+	stats     processorStats
+
+	enqueueTimes sync.Map
+	queuedCount  int64
+
+	fetchGroup singleflightGroup
+}
+
+// processorStats are the internal telegraf stats emitted under the
+// internal_gce_processor measurement.
+type processorStats struct {
+	errors      selfstat.Stat
+	timeouts    selfstat.Stat
+	cacheHits   selfstat.Stat
+	circuitOpen selfstat.Stat
+}
+
+// cacheEntry holds a single cached metadata value and when it was fetched,
+// so the refresh loop knows whether it is due for another lookup. A failed
+// fetch is cached too (negative=true, err set) for negativeCacheTTL, so a
+// persistently broken tag doesn't force every metric back onto the
+// synchronous fetch path until the next background refresh.
+type cacheEntry struct {
+	value     string
+	err       error
+	negative  bool
+	fetchedAt time.Time
+}
+
+// MetadataEntry declares an arbitrary GCE metadata path to fetch and attach
+// as a tag, beyond the four well-known values covered by allow_tags.
+type MetadataEntry struct {
+	Tag       string `toml:"tag"`
+	Path      string `toml:"path"`
+	Transform string `toml:"transform"`
 }
 
 const sampleConfig = `
@@ -55,14 +129,143 @@ const sampleConfig = `
   ## at the same time.
   ## It's probably best to keep this number fairly low.
   max_parallel_calls = 10
+
+  ## metadata_refresh_interval controls how often cached metadata values are
+  ## refreshed in the background. Most GCE metadata (zone, name, hostname)
+  ## never changes for the life of the instance, so values are served from
+  ## an in-memory cache rather than fetched on every metric. A value of "0"
+  ## disables the background refresh and fetches each tag once, at Start.
+  metadata_refresh_interval = "5m"
+
+  ## adaptive_concurrency, when true, additionally bounds how many of the
+  ## max_parallel_calls workers may be resolving GCE metadata tags at once,
+  ## tuning that bound between min_parallel_calls and max_parallel_calls
+  ## with an AIMD controller: the limit is increased by one each
+  ## adaptive_window as long as observed p95 latency and error rate stay
+  ## under target, and is halved the moment either threshold is crossed.
+  ## max_parallel_calls still sizes the worker pool itself; this only
+  ## throttles concurrency within it.
+  adaptive_concurrency = false
+
+  ## min_parallel_calls is the floor the adaptive controller will not back
+  ## off below. Only used when adaptive_concurrency is true.
+  min_parallel_calls = 1
+
+  ## adaptive_target_latency is the p95 latency of getTagFromGCE calls, over
+  ## adaptive_window, below which the controller is allowed to grow the
+  ## limit. Only used when adaptive_concurrency is true.
+  adaptive_target_latency = "200ms"
+
+  ## adaptive_error_threshold is the fraction of failed metadata calls,
+  ## over adaptive_window, above which the controller halves the limit.
+  ## Only used when adaptive_concurrency is true.
+  adaptive_error_threshold = 0.01
+
+  ## adaptive_window is the rolling window over which latency and error
+  ## rate are measured for the adaptive controller.
+  adaptive_window = "10s"
+
+  ## max_queue_wait is the longest a metric may sit in the internal queue
+  ## waiting for its metadata lookup to start before it is passed downstream
+  ## untagged (tagged with gce_metadata_status="timeout" instead). A value
+  ## of "0" disables the timeout and metrics wait indefinitely.
+  max_queue_wait = "2s"
+
+  ## max_queued_metrics is a hard cap on the number of metrics allowed to be
+  ## queued awaiting a metadata lookup. A value of "0" disables the cap.
+  max_queued_metrics = 0
+
+  ## queue_overflow_policy controls what happens to a metric that arrives
+  ## once max_queued_metrics has been reached. "passthrough" emits the
+  ## metric untagged (tagged with gce_metadata_status="queue_full"),
+  ## "drop" discards it.
+  queue_overflow_policy = "passthrough"
+
+  ## metadata declares arbitrary GCE metadata paths to fetch and attach as
+  ## tags, for anything not covered by the four well-known allow_tags
+  ## values. path is resolved against the metadata server's
+  ## computeMetadata/v1/ tree (e.g. "instance/machine-type",
+  ## "instance/attributes/env", "project/project-id"). transform is
+  ## optional and one of "basename" (keep the segment after the last "/"),
+  ## "trim" (trim surrounding whitespace), or "json:<field>" (parse the
+  ## value as JSON and extract field).
+  ##
+  ## [[metadata]]
+  ##   tag = "machine_type"
+  ##   path = "instance/machine-type"
+  ##   transform = "basename"
+  ##
+  ## [[metadata]]
+  ##   tag = "env"
+  ##   path = "instance/attributes/env"
+
+  ## retry_max_attempts is the total number of attempts (the first call
+  ## included) made for a metadata call, with exponential backoff between
+  ## them, before giving up. A backoff wait is cut short at the overall
+  ## timeout, and no further attempt is made when the timeout has already
+  ## elapsed after a failed attempt.
+  retry_max_attempts = 3
+
+  ## circuit_breaker_threshold is the number of consecutive metadata call
+  ## failures (across all tags) after which the circuit breaker opens,
+  ## short-circuiting further lookups until circuit_breaker_cooldown has
+  ## elapsed. While open, metrics pass through tagged with
+  ## gce_metadata_error="circuit_open" instead of blocking on more failures.
+  circuit_breaker_threshold = 5
+
+  ## circuit_breaker_cooldown is how long the circuit breaker stays open
+  ## once tripped before allowing metadata calls again.
+  circuit_breaker_cooldown = "30s"
+
+  ## enable_http2 controls whether the client to the metadata server may
+  ## negotiate HTTP/2. Disabling this can help in environments where HTTP/2
+  ## connection reuse to the metadata server causes head-of-line blocking
+  ## during transient throttling.
+  enable_http2 = true
+
+  ## proxy_url, if set, routes metadata server requests through this proxy.
+  ## Useful in sidecar setups where the metadata endpoint is only reachable
+  ## through a proxy.
+  proxy_url = ""
+
+  ## tls_insecure_skip_verify disables TLS certificate verification for
+  ## requests to the metadata server. Use with caution.
+  tls_insecure_skip_verify = false
 `
 
 const (
-	DefaultMaxOrderedQueueSize = 10_000
-	DefaultMaxParallelCalls    = 10
-	DefaultTimeout             = 10 * time.Second
+	DefaultMaxOrderedQueueSize     = 10_000
+	DefaultMaxParallelCalls        = 10
+	DefaultTimeout                 = 10 * time.Second
+	DefaultMetadataRefreshInterval = 5 * time.Minute
+
+	DefaultMinParallelCalls      = 1
+	DefaultAdaptiveTargetLatency = 200 * time.Millisecond
+	DefaultAdaptiveErrorThresh   = 0.01
+	DefaultAdaptiveWindow        = 10 * time.Second
+
+	DefaultMaxQueueWait        = 2 * time.Second
+	DefaultQueueOverflowPolicy = "passthrough"
+
+	DefaultRetryMaxAttempts        = 3
+	DefaultCircuitBreakerThreshold = 5
+	DefaultCircuitBreakerCooldown  = 30 * time.Second
+
+	retryBaseBackoff = 50 * time.Millisecond
+	retryMaxBackoff  = 2 * time.Second
+
+	// negativeCacheTTL bounds how long a failed fetch is cached before the
+	// next call is allowed to try the metadata endpoint again.
+	negativeCacheTTL = 30 * time.Second
+)
+
+const (
+	gceMetadataStatusTag = "gce_metadata_status"
+	gceMetadataErrorTag  = "gce_metadata_error"
 )
 
+var errCircuitOpen = errors.New("gce metadata circuit breaker open")
+
 var permittedTags = map[string]struct{}{
 	"zone":     {},
 	"tags":     {},
@@ -78,11 +281,31 @@ func (r *GceProcessor) Description() string {
 	return "Attach GCE metadata to metrics"
 }
 
-func (r *GceProcessor) Add(metric telegraf.Metric, _ telegraf.Accumulator) error {
+func (r *GceProcessor) Add(metric telegraf.Metric, acc telegraf.Accumulator) error {
+	n := atomic.AddInt64(&r.queuedCount, 1)
+	if r.MaxQueuedMetrics > 0 && n > int64(r.MaxQueuedMetrics) {
+		atomic.AddInt64(&r.queuedCount, -1)
+		return r.handleQueueOverflow(metric, acc)
+	}
+
+	r.enqueueTimes.Store(metric, time.Now())
 	r.parallel.Enqueue(metric)
 	return nil
 }
 
+// handleQueueOverflow applies QueueOverflowPolicy to a metric that arrived
+// once MaxQueuedMetrics was already reached.
+func (r *GceProcessor) handleQueueOverflow(metric telegraf.Metric, acc telegraf.Accumulator) error {
+	if r.QueueOverflowPolicy == "drop" {
+		r.Log.Warnf("gce processor queue full (max_queued_metrics=%d); dropping metric", r.MaxQueuedMetrics)
+		return nil
+	}
+
+	metric.AddTag(gceMetadataStatusTag, "queue_full")
+	acc.AddMetric(metric)
+	return nil
+}
+
 func (r *GceProcessor) Init() error {
 	r.Log.Debug("Initializing GCE Processor")
 	for _, tag := range r.AllowTags {
@@ -92,12 +315,77 @@ func (r *GceProcessor) Init() error {
 		r.allowTagsMap[tag] = struct{}{}
 	}
 
+	for _, entry := range r.Metadata {
+		if len(entry.Tag) == 0 || len(entry.Path) == 0 {
+			return fmt.Errorf("metadata entries require both a tag and a path: %+v", entry)
+		}
+		if isTagPermitted(entry.Tag) {
+			return fmt.Errorf("metadata tag %q collides with a well-known allow_tags value", entry.Tag)
+		}
+		r.metadataEntries[entry.Tag] = entry
+	}
+
+	if r.AdaptiveConcurrency && r.MaxParallelCalls < 1 {
+		return fmt.Errorf("adaptive_concurrency requires max_parallel_calls >= 1, got %d", r.MaxParallelCalls)
+	}
+
 	return nil
 }
 
+// allTags returns every tag this processor is configured to attach, from
+// both allow_tags and the metadata section.
+func (r *GceProcessor) allTags() []string {
+	tags := make([]string, 0, len(r.allowTagsMap)+len(r.metadataEntries))
+	for tag := range r.allowTagsMap {
+		tags = append(tags, tag)
+	}
+	for tag := range r.metadataEntries {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
 func (r *GceProcessor) Start(acc telegraf.Accumulator) error {
-	r.gceClient = metadata.NewClient(nil)
+	httpClient, err := r.newHTTPClient()
+	if err != nil {
+		return err
+	}
+	r.gceClient = metadata.NewClient(httpClient)
+	r.cache = make(map[string]*cacheEntry)
+	r.done = make(chan struct{})
+
+	r.breaker = newCircuitBreaker(r.CircuitBreakerThreshold, time.Duration(r.CircuitBreakerCooldown))
+	r.stats = processorStats{
+		errors:      selfstat.Register("gce_processor", "errors", map[string]string{}),
+		timeouts:    selfstat.Register("gce_processor", "timeouts", map[string]string{}),
+		cacheHits:   selfstat.Register("gce_processor", "cache_hits", map[string]string{}),
+		circuitOpen: selfstat.Register("gce_processor", "circuit_open", map[string]string{}),
+	}
 
+	if r.AdaptiveConcurrency {
+		min := r.MinParallelCalls
+		if min < 1 {
+			min = 1
+		}
+		r.limiter = newAdaptiveLimiter(min, r.MaxParallelCalls)
+		r.limitStat = selfstat.Register("gce_processor", "max_parallel_calls", map[string]string{})
+		go r.adaptiveLoop(time.Duration(r.AdaptiveWindow))
+	}
+
+	// Pre-warm the cache so the first metric through the pipeline doesn't
+	// pay the metadata-endpoint latency cost. Tags are warmed concurrently
+	// rather than one at a time, since there's no reason to pay for
+	// metadata-endpoint round trips sequentially when they're independent.
+	r.refreshTags(r.allTags(), "pre-warm")
+
+	if r.MetadataRefreshInterval > 0 {
+		go r.refreshLoop(time.Duration(r.MetadataRefreshInterval))
+	}
+
+	// The worker pool itself is always sized to MaxParallelCalls; when
+	// adaptive_concurrency is enabled, asyncAdd additionally gates on
+	// r.limiter so the number of workers actually doing metadata-resolution
+	// work at once can be tuned below that fixed pool size.
 	if r.Ordered {
 		r.parallel = parallel.NewOrdered(acc, r.asyncAdd, DefaultMaxOrderedQueueSize, r.MaxParallelCalls)
 	} else {
@@ -107,27 +395,228 @@ func (r *GceProcessor) Start(acc telegraf.Accumulator) error {
 	return nil
 }
 
+// newHTTPClient builds the *http.Client used to talk to the metadata server,
+// honoring Timeout, EnableHTTP2, ProxyURL and TLSInsecureSkipVerify.
+func (r *GceProcessor) newHTTPClient() (*http.Client, error) {
+	transport := &http.Transport{}
+
+	if r.ProxyURL != "" {
+		proxyURL, err := url.Parse(r.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy_url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if r.TLSInsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	if !r.EnableHTTP2 {
+		// An empty, non-nil TLSNextProto disables HTTP/2 protocol negotiation.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &http.Client{
+		Timeout:   time.Duration(r.Timeout),
+		Transport: transport,
+	}, nil
+}
+
 func (r *GceProcessor) Stop() error {
 	if r.parallel == nil {
 		return errors.New("trying to stop unstarted GCE Processor")
 	}
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
 	r.parallel.Stop()
 	return nil
 }
 
+// refreshLoop re-fetches every configured tag once per interval, so cached
+// values stay current while the hot path in asyncAdd is served from the
+// cache.
+func (r *GceProcessor) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.refreshTags(r.allTags(), "refresh")
+		}
+	}
+}
+
+// refreshTags refreshes every tag in tags concurrently, since the fetches
+// are independent and there's no reason to pay for them one at a time.
+// source is used only for the error log message.
+func (r *GceProcessor) refreshTags(tags []string, source string) {
+	var wg sync.WaitGroup
+	for _, tag := range tags {
+		wg.Add(1)
+		go func(tag string) {
+			defer wg.Done()
+			if _, err := r.refreshTag(tag); err != nil {
+				r.Log.Errorf("unable to %s metadata tag %q: %v", source, tag, err)
+			}
+		}(tag)
+	}
+	wg.Wait()
+}
+
+// refreshTag fetches tag from the GCE metadata endpoint and stores it in the
+// cache, regardless of whether a cached value is already present. Concurrent
+// callers for the same tag are collapsed into a single underlying fetch via
+// fetchGroup, so a persistently failing tag can't trigger a thundering herd
+// of retries against the metadata endpoint every time the circuit breaker's
+// cooldown elapses.
+func (r *GceProcessor) refreshTag(tag string) (string, error) {
+	return r.fetchGroup.do(tag, func() (string, error) {
+		return r.doRefreshTag(tag)
+	})
+}
+
+// doRefreshTag performs the actual fetch (with retry and circuit breaker
+// gating) and caches the outcome, positive or negative. Adaptive-concurrency
+// gating happens one level up, in asyncAdd, since that's what actually
+// bounds the work the request rate produces; gating here too would mean an
+// asyncAdd-held limiter slot recursing into a second acquire on its own
+// cache-miss fallback call, which would deadlock at a limit of 1.
+func (r *GceProcessor) doRefreshTag(tag string) (string, error) {
+	if !r.breaker.allow() {
+		r.stats.circuitOpen.Incr(1)
+		r.cacheError(tag, errCircuitOpen)
+		return "", errCircuitOpen
+	}
+
+	val, err := r.fetchWithRetry(tag)
+	r.breaker.recordResult(err)
+	if err != nil {
+		r.stats.errors.Incr(1)
+		r.cacheError(tag, err)
+		return "", err
+	}
+
+	r.cacheMu.Lock()
+	r.cache[tag] = &cacheEntry{value: val, fetchedAt: time.Now()}
+	r.cacheMu.Unlock()
+
+	return val, nil
+}
+
+// cacheError records a failed fetch as a negative cache entry, so repeat
+// callers are served the cached error instead of re-hitting the endpoint
+// until negativeCacheTTL elapses. It never clobbers an existing positive
+// entry: most of the well-known tags (zone, name, hostname) are constant
+// for the life of the instance, so a transient refresh failure should fall
+// back to the last known-good value rather than serve errors to every
+// metric until the negative entry ages out.
+func (r *GceProcessor) cacheError(tag string, err error) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	if existing, ok := r.cache[tag]; ok && !existing.negative {
+		return
+	}
+	r.cache[tag] = &cacheEntry{err: err, negative: true, fetchedAt: time.Now()}
+}
+
+// fetchWithRetry calls fetchTagFromGCE up to RetryMaxAttempts times in total,
+// with exponential backoff between attempts. A backoff is cut short at
+// Timeout, and no further attempt is made when Timeout has already elapsed
+// after a failed attempt.
+func (r *GceProcessor) fetchWithRetry(tag string) (string, error) {
+	deadline := time.Now().Add(time.Duration(r.Timeout))
+
+	var lastErr error
+	for attempt := 0; attempt < r.RetryMaxAttempts; attempt++ {
+		val, err := r.fetchTagFromGCE(tag)
+		if err == nil {
+			return val, nil
+		}
+		lastErr = err
+
+		if attempt == r.RetryMaxAttempts-1 {
+			break
+		}
+		backoff := retryBaseBackoff * time.Duration(1<<uint(attempt))
+		if backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+		if remaining := time.Until(deadline); remaining <= 0 {
+			break
+		} else if remaining < backoff {
+			backoff = remaining
+		}
+		time.Sleep(backoff)
+	}
+
+	return "", lastErr
+}
+
+// adaptiveLoop re-tunes the adaptive concurrency limit once per window and
+// publishes it as an internal telegraf stat.
+func (r *GceProcessor) adaptiveLoop(window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.limiter.tick(time.Duration(r.AdaptiveTargetLatency), r.AdaptiveErrorThresh)
+			r.limitStat.Set(int64(r.limiter.currentLimit()))
+		}
+	}
+}
+
+// asyncAdd is the worker callback the parallel pool runs per metric. The
+// pool itself is always sized to MaxParallelCalls; when adaptive_concurrency
+// is enabled, this is also where the adaptive limit is actually enforced, by
+// gating how many of those workers may be resolving metadata tags at once.
 func (r *GceProcessor) asyncAdd(metric telegraf.Metric) []telegraf.Metric {
-	_, cancel := context.WithTimeout(context.Background(), time.Duration(r.Timeout))
-	defer cancel()
-
-	if len(r.allowTagsMap) > 0 {
-		for tag := range r.allowTagsMap {
-			fmt.Println(tag)
-			val, err := r.getTagFromGCE(tag)
-			if err != nil {
-				panic(err)
+	defer atomic.AddInt64(&r.queuedCount, -1)
+
+	if enqueuedAt, ok := r.enqueueTimes.Load(metric); ok {
+		r.enqueueTimes.Delete(metric)
+		if r.MaxQueueWait > 0 && time.Since(enqueuedAt.(time.Time)) > time.Duration(r.MaxQueueWait) {
+			r.stats.timeouts.Incr(1)
+			metric.AddTag(gceMetadataStatusTag, "timeout")
+			return []telegraf.Metric{metric}
+		}
+	}
+
+	hadError := false
+	circuitOpen := false
+	for _, tag := range r.allTags() {
+		if r.limiter != nil {
+			r.limiter.acquire()
+		}
+		start := time.Now()
+		val, err := r.getTagFromGCE(tag)
+		if r.limiter != nil {
+			r.limiter.release(time.Since(start), err)
+		}
+		if err != nil {
+			r.Log.Errorf("unable to fetch metadata tag %q, skipping: %v", tag, err)
+			hadError = true
+			if errors.Is(err, errCircuitOpen) {
+				circuitOpen = true
 			}
-			metric.AddTag(tag, val)
+			continue
 		}
+		metric.AddTag(tag, val)
+	}
+
+	switch {
+	case circuitOpen:
+		metric.AddTag(gceMetadataErrorTag, "circuit_open")
+	case hadError:
+		metric.AddTag(gceMetadataErrorTag, "error")
 	}
 
 	return []telegraf.Metric{metric}
@@ -141,13 +630,48 @@ func init() {
 
 func newGceProcessor() *GceProcessor {
 	return &GceProcessor{
-		MaxParallelCalls: DefaultMaxParallelCalls,
-		Timeout:          config.Duration(DefaultTimeout),
-		allowTagsMap:     make(map[string]struct{}),
+		MaxParallelCalls:        DefaultMaxParallelCalls,
+		Timeout:                 config.Duration(DefaultTimeout),
+		MetadataRefreshInterval: config.Duration(DefaultMetadataRefreshInterval),
+		MinParallelCalls:        DefaultMinParallelCalls,
+		AdaptiveTargetLatency:   config.Duration(DefaultAdaptiveTargetLatency),
+		AdaptiveErrorThresh:     DefaultAdaptiveErrorThresh,
+		AdaptiveWindow:          config.Duration(DefaultAdaptiveWindow),
+		MaxQueueWait:            config.Duration(DefaultMaxQueueWait),
+		QueueOverflowPolicy:     DefaultQueueOverflowPolicy,
+		RetryMaxAttempts:        DefaultRetryMaxAttempts,
+		CircuitBreakerThreshold: DefaultCircuitBreakerThreshold,
+		CircuitBreakerCooldown:  config.Duration(DefaultCircuitBreakerCooldown),
+		EnableHTTP2:             true,
+		allowTagsMap:            make(map[string]struct{}),
+		metadataEntries:         make(map[string]MetadataEntry),
 	}
 }
 
+// getTagFromGCE returns tag's value, serving it from the cache when present.
+// A cache miss falls back to a synchronous fetch so callers always get a
+// value, but in steady state (once Start and the refresh loop have run) this
+// is expected to always be a cache hit.
 func (r *GceProcessor) getTagFromGCE(tag string) (string, error) {
+	r.cacheMu.RLock()
+	entry, ok := r.cache[tag]
+	r.cacheMu.RUnlock()
+	if ok {
+		if entry.negative {
+			if time.Since(entry.fetchedAt) < negativeCacheTTL {
+				return "", entry.err
+			}
+			// Negative entry has aged out; fall through and try again.
+		} else {
+			r.stats.cacheHits.Incr(1)
+			return entry.value, nil
+		}
+	}
+
+	return r.refreshTag(tag)
+}
+
+func (r *GceProcessor) fetchTagFromGCE(tag string) (string, error) {
 	switch tag {
 	case "zone":
 		zone, err := r.gceClient.Zone()
@@ -174,7 +698,42 @@ func (r *GceProcessor) getTagFromGCE(tag string) (string, error) {
 		}
 		return hostname, nil
 	default:
-		return "", nil
+		entry, ok := r.metadataEntries[tag]
+		if !ok {
+			return "", fmt.Errorf("no metadata source configured for tag %q", tag)
+		}
+		val, err := r.gceClient.Get(entry.Path)
+		if err != nil {
+			return "", err
+		}
+		return applyTransform(val, entry.Transform)
+	}
+}
+
+// applyTransform post-processes a raw metadata value for a [[metadata]]
+// entry. An empty transform returns the value unchanged.
+func applyTransform(val, transform string) (string, error) {
+	switch {
+	case transform == "":
+		return val, nil
+	case transform == "basename":
+		parts := strings.Split(strings.TrimRight(val, "/"), "/")
+		return parts[len(parts)-1], nil
+	case transform == "trim":
+		return strings.TrimSpace(val), nil
+	case strings.HasPrefix(transform, "json:"):
+		field := strings.TrimPrefix(transform, "json:")
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(val), &parsed); err != nil {
+			return "", fmt.Errorf("transform json:%s: %w", field, err)
+		}
+		v, ok := parsed[field]
+		if !ok {
+			return "", fmt.Errorf("transform json:%s: field not present", field)
+		}
+		return fmt.Sprintf("%v", v), nil
+	default:
+		return "", fmt.Errorf("unknown metadata transform: %s", transform)
 	}
 }
 
@@ -182,3 +741,204 @@ func isTagPermitted(tag string) bool {
 	_, ok := permittedTags[tag]
 	return ok
 }
+
+// singleflightGroup collapses concurrent callers requesting the same key
+// into a single underlying call, so a tag that many goroutines want at once
+// only hits the metadata endpoint once.
+type singleflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*singleflightCall
+}
+
+type singleflightCall struct {
+	wg  sync.WaitGroup
+	val string
+	err error
+}
+
+func (g *singleflightGroup) do(key string, fn func() (string, error)) (string, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*singleflightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := &singleflightCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return c.val, c.err
+}
+
+// circuitBreaker short-circuits metadata lookups after threshold consecutive
+// failures, refusing further calls until cooldown has elapsed since the
+// breaker tripped. A threshold of 0 disables the breaker.
+type circuitBreaker struct {
+	mu               sync.Mutex
+	consecutiveFails int
+	openUntil        time.Time
+	threshold        int
+	cooldown         time.Duration
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may proceed right now.
+func (c *circuitBreaker) allow() bool {
+	if c.threshold <= 0 {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().After(c.openUntil)
+}
+
+// recordResult updates the consecutive-failure count and trips the breaker
+// once threshold is reached.
+func (c *circuitBreaker) recordResult(err error) {
+	if c.threshold <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.consecutiveFails = 0
+		return
+	}
+	c.consecutiveFails++
+	if c.consecutiveFails >= c.threshold {
+		c.openUntil = time.Now().Add(c.cooldown)
+		c.consecutiveFails = 0
+	}
+}
+
+// adaptiveLimiter bounds the number of in-flight metadata calls between min
+// and max, adjusting the current limit once per window with an AIMD rule:
+// additive increase while latency and errors stay under target, multiplicative
+// decrease (floored at min) the moment either threshold is crossed. Modeled
+// after Gitaly's limithandler adaptive concurrency limiter.
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight int
+	limit    int
+	min      int
+	max      int
+
+	samplesMu sync.Mutex
+	latencies []time.Duration
+	calls     int
+	errors    int
+}
+
+// newAdaptiveLimiter builds a limiter bounded to [min, max]. max is floored
+// at 1 and min is clamped into [1, max] so acquire() can never be asked to
+// wait on a zero-sized limit, which would block forever.
+func newAdaptiveLimiter(min, max int) *adaptiveLimiter {
+	if max < 1 {
+		max = 1
+	}
+	if min < 1 {
+		min = 1
+	}
+	if min > max {
+		min = max
+	}
+	l := &adaptiveLimiter{limit: max, min: min, max: max}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until the current limit allows another call to proceed.
+func (l *adaptiveLimiter) acquire() {
+	l.mu.Lock()
+	for l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+	l.mu.Unlock()
+}
+
+// release records the outcome of a completed call and frees a slot.
+func (l *adaptiveLimiter) release(latency time.Duration, err error) {
+	l.mu.Lock()
+	l.inFlight--
+	l.cond.Signal()
+	l.mu.Unlock()
+
+	l.samplesMu.Lock()
+	l.latencies = append(l.latencies, latency)
+	l.calls++
+	if err != nil {
+		l.errors++
+	}
+	l.samplesMu.Unlock()
+}
+
+func (l *adaptiveLimiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// tick evaluates the samples collected since the last tick and applies the
+// AIMD adjustment. It is a no-op if no calls were observed this window.
+func (l *adaptiveLimiter) tick(targetLatency time.Duration, errorThreshold float64) {
+	l.samplesMu.Lock()
+	latencies := l.latencies
+	calls := l.calls
+	errs := l.errors
+	l.latencies = nil
+	l.calls = 0
+	l.errors = 0
+	l.samplesMu.Unlock()
+
+	if calls == 0 {
+		return
+	}
+
+	errorRatio := float64(errs) / float64(calls)
+	p95 := percentile(latencies, 0.95)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if errorRatio > errorThreshold || p95 > targetLatency {
+		newLimit := l.limit / 2
+		if newLimit < l.min {
+			newLimit = l.min
+		}
+		l.limit = newLimit
+	} else if l.limit < l.max {
+		l.limit++
+	}
+	l.cond.Broadcast()
+}
+
+func percentile(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
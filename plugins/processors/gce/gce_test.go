@@ -0,0 +1,369 @@
+package ec2
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestApplyTransform(t *testing.T) {
+	tests := []struct {
+		name      string
+		val       string
+		transform string
+		expected  string
+		wantErr   bool
+	}{
+		{name: "no transform", val: "n1-standard-1", transform: "", expected: "n1-standard-1"},
+		{name: "basename", val: "projects/123/machineTypes/n1-standard-1", transform: "basename", expected: "n1-standard-1"},
+		{name: "basename trailing slash", val: "projects/123/machineTypes/n1-standard-1/", transform: "basename", expected: "n1-standard-1"},
+		{name: "trim", val: "  prod  \n", transform: "trim", expected: "prod"},
+		{name: "json field", val: `{"env":"prod","tier":"1"}`, transform: "json:env", expected: "prod"},
+		{name: "json missing field", val: `{"env":"prod"}`, transform: "json:tier", wantErr: true},
+		{name: "json invalid", val: "not json", transform: "json:env", wantErr: true},
+		{name: "unknown transform", val: "x", transform: "upper", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyTransform(tt.val, tt.transform)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	require.Equal(t, time.Duration(0), percentile(nil, 0.95))
+
+	values := []time.Duration{
+		100 * time.Millisecond,
+		400 * time.Millisecond,
+		300 * time.Millisecond,
+		200 * time.Millisecond,
+		500 * time.Millisecond,
+	}
+	// Sorted: 100,200,300,400,500ms. idx = int(0.95*5) = 4 -> 500ms.
+	require.Equal(t, 500*time.Millisecond, percentile(values, 0.95))
+	// idx = int(0*5) = 0 -> 100ms.
+	require.Equal(t, 100*time.Millisecond, percentile(values, 0))
+}
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	cb := newCircuitBreaker(2, 10*time.Millisecond)
+	require.True(t, cb.allow())
+
+	cb.recordResult(errors.New("boom"))
+	require.True(t, cb.allow(), "breaker should stay closed under threshold")
+
+	cb.recordResult(errors.New("boom"))
+	require.False(t, cb.allow(), "breaker should open once threshold consecutive failures are hit")
+
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, cb.allow(), "breaker should close again after cooldown elapses")
+
+	cb.recordResult(errors.New("boom"))
+	cb.recordResult(nil)
+	require.True(t, cb.allow(), "a success should reset the consecutive failure count")
+}
+
+func TestCircuitBreakerDisabledWhenThresholdIsZero(t *testing.T) {
+	cb := newCircuitBreaker(0, time.Hour)
+	for i := 0; i < 10; i++ {
+		cb.recordResult(errors.New("boom"))
+	}
+	require.True(t, cb.allow())
+}
+
+func TestAdaptiveLimiterClampsBounds(t *testing.T) {
+	l := newAdaptiveLimiter(0, 0)
+	require.Equal(t, 1, l.currentLimit(), "a zero max must be floored to avoid a permanently deadlocked acquire()")
+
+	l = newAdaptiveLimiter(10, 5)
+	require.Equal(t, 5, l.currentLimit(), "min above max should clamp down to max")
+}
+
+func TestAdaptiveLimiterAcquireRelease(t *testing.T) {
+	l := newAdaptiveLimiter(1, 1)
+
+	done := make(chan struct{})
+	l.acquire()
+	go func() {
+		l.acquire()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("second acquire should block while the only slot is held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.release(time.Millisecond, nil)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("second acquire should unblock after release")
+	}
+	l.release(time.Millisecond, nil)
+}
+
+func TestAdaptiveLimiterTick(t *testing.T) {
+	l := newAdaptiveLimiter(1, 10)
+	l.limit = 5
+
+	// Fast, error-free calls should grow the limit by one.
+	l.release(10*time.Millisecond, nil)
+	l.tick(200*time.Millisecond, 0.01)
+	require.Equal(t, 6, l.currentLimit())
+
+	// A high error ratio should halve the limit.
+	l.release(10*time.Millisecond, errors.New("boom"))
+	l.tick(200*time.Millisecond, 0.01)
+	require.Equal(t, 3, l.currentLimit())
+
+	// Slow calls should also halve the limit, floored at min.
+	l.limit = 2
+	l.release(900*time.Millisecond, nil)
+	l.tick(200*time.Millisecond, 0.01)
+	require.Equal(t, 1, l.currentLimit())
+
+	// A tick with no samples is a no-op.
+	l.tick(200*time.Millisecond, 0.01)
+	require.Equal(t, 1, l.currentLimit())
+}
+
+func TestSingleflightGroupCollapsesConcurrentCallers(t *testing.T) {
+	var g singleflightGroup
+
+	var calls int32
+	start := make(chan struct{})
+	results := make(chan string, 5)
+
+	for i := 0; i < 5; i++ {
+		go func() {
+			<-start
+			val, err := g.do("tag", func() (string, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(20 * time.Millisecond)
+				return "value", nil
+			})
+			require.NoError(t, err)
+			results <- val
+		}()
+	}
+	close(start)
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, "value", <-results)
+	}
+	require.EqualValues(t, 1, calls, "concurrent callers for the same key should share a single underlying call")
+}
+
+func TestAddRejectsWhenQueueFullPolicyDrop(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.MaxQueuedMetrics = 1
+	r.QueueOverflowPolicy = "drop"
+	r.queuedCount = 1
+
+	acc := &testutil.Accumulator{}
+	m := testutil.MustMetric("cpu", nil, map[string]interface{}{"value": 1}, time.Now())
+	require.NoError(t, r.Add(m, acc))
+	require.Empty(t, acc.GetTelegrafMetrics(), "a dropped metric should never reach the accumulator")
+}
+
+func TestAddRejectsWhenQueueFullPolicyPassthrough(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.MaxQueuedMetrics = 1
+	r.QueueOverflowPolicy = "passthrough"
+	r.queuedCount = 1
+
+	acc := &testutil.Accumulator{}
+	m := testutil.MustMetric("cpu", nil, map[string]interface{}{"value": 1}, time.Now())
+	require.NoError(t, r.Add(m, acc))
+
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 1)
+	tag, ok := metrics[0].GetTag(gceMetadataStatusTag)
+	require.True(t, ok)
+	require.Equal(t, "queue_full", tag)
+}
+
+func TestAsyncAddTagsMetricThatWaitedPastMaxQueueWait(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.MaxQueueWait = config.Duration(10 * time.Millisecond)
+	r.stats.timeouts = selfstat.Register("gce_processor", "timeouts", map[string]string{})
+
+	m := testutil.MustMetric("cpu", nil, map[string]interface{}{"value": 1}, time.Now())
+	r.enqueueTimes.Store(m, time.Now().Add(-time.Second))
+	r.queuedCount = 1
+
+	out := r.asyncAdd(m)
+	require.Len(t, out, 1)
+	tag, ok := out[0].GetTag(gceMetadataStatusTag)
+	require.True(t, ok)
+	require.Equal(t, "timeout", tag)
+}
+
+func TestAsyncAddSkipsTimeoutCheckWhenMaxQueueWaitDisabled(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.MaxQueueWait = 0
+
+	m := testutil.MustMetric("cpu", nil, map[string]interface{}{"value": 1}, time.Now())
+	r.enqueueTimes.Store(m, time.Now().Add(-time.Hour))
+	r.queuedCount = 1
+
+	out := r.asyncAdd(m)
+	require.Len(t, out, 1)
+	_, ok := out[0].GetTag(gceMetadataStatusTag)
+	require.False(t, ok, "max_queue_wait=0 should never tag a metric as timed out")
+}
+
+func TestInitRejectsUnpermittedAllowTag(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.AllowTags = []string{"bogus"}
+
+	require.Error(t, r.Init())
+}
+
+func TestInitAcceptsPermittedAllowTags(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.AllowTags = []string{"zone", "name"}
+
+	require.NoError(t, r.Init())
+	require.Contains(t, r.allowTagsMap, "zone")
+	require.Contains(t, r.allowTagsMap, "name")
+}
+
+func TestInitRejectsMetadataEntryMissingTagOrPath(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.Metadata = []MetadataEntry{{Tag: "env"}}
+	require.Error(t, r.Init())
+
+	r = newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.Metadata = []MetadataEntry{{Path: "instance/attributes/env"}}
+	require.Error(t, r.Init())
+}
+
+func TestInitRejectsMetadataTagCollidingWithWellKnownTag(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.Metadata = []MetadataEntry{{Tag: "zone", Path: "instance/zone"}}
+
+	require.Error(t, r.Init())
+}
+
+func TestInitAcceptsValidMetadataEntry(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.Metadata = []MetadataEntry{{Tag: "env", Path: "instance/attributes/env", Transform: "trim"}}
+
+	require.NoError(t, r.Init())
+	require.Contains(t, r.metadataEntries, "env")
+}
+
+func TestInitRejectsAdaptiveConcurrencyWithoutMaxParallelCalls(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.AdaptiveConcurrency = true
+	r.MaxParallelCalls = 0
+
+	require.Error(t, r.Init(), "adaptive_concurrency requires max_parallel_calls >= 1")
+}
+
+func TestInitAllowsAdaptiveConcurrencyWithMaxParallelCalls(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.AdaptiveConcurrency = true
+
+	require.NoError(t, r.Init())
+}
+
+func TestNewHTTPClientDefaults(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.Timeout = config.Duration(5 * time.Second)
+
+	client, err := r.newHTTPClient()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, client.Timeout)
+
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Nil(t, transport.Proxy)
+	require.Nil(t, transport.TLSClientConfig)
+	require.Nil(t, transport.TLSNextProto, "HTTP/2 should remain enabled by default")
+}
+
+func TestNewHTTPClientAppliesProxyURL(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.ProxyURL = "http://proxy.example.com:8080"
+
+	client, err := r.newHTTPClient()
+	require.NoError(t, err)
+
+	transport := client.Transport.(*http.Transport)
+	require.NotNil(t, transport.Proxy)
+
+	req, err := http.NewRequest(http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/", nil)
+	require.NoError(t, err)
+	proxyURL, err := transport.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "proxy.example.com:8080", proxyURL.Host)
+}
+
+func TestNewHTTPClientRejectsInvalidProxyURL(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.ProxyURL = "://not-a-valid-url"
+
+	_, err := r.newHTTPClient()
+	require.Error(t, err)
+}
+
+func TestNewHTTPClientTLSInsecureSkipVerify(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.TLSInsecureSkipVerify = true
+
+	client, err := r.newHTTPClient()
+	require.NoError(t, err)
+
+	transport := client.Transport.(*http.Transport)
+	require.NotNil(t, transport.TLSClientConfig)
+	require.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+}
+
+func TestNewHTTPClientDisablesHTTP2(t *testing.T) {
+	r := newGceProcessor()
+	r.Log = testutil.Logger{}
+	r.EnableHTTP2 = false
+
+	client, err := r.newHTTPClient()
+	require.NoError(t, err)
+
+	transport := client.Transport.(*http.Transport)
+	require.NotNil(t, transport.TLSNextProto)
+	require.Empty(t, transport.TLSNextProto)
+}